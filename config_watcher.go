@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConfigWatcher watches servers.json for changes, either via filesystem
+// events or a SIGHUP, and atomically swaps the server list inside its
+// MetricsCollector so that adding or removing servers doesn't require a
+// restart and the resulting scrape gap.
+type ConfigWatcher struct {
+	path      string
+	collector *MetricsCollector
+	watcher   *fsnotify.Watcher
+	sighup    chan os.Signal
+	done      chan struct{}
+}
+
+// NewConfigWatcher creates a watcher for path, ready to be started with Start.
+func NewConfigWatcher(path string, collector *MetricsCollector) (*ConfigWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fsnotify watcher: %w", err)
+	}
+
+	// Watch the containing directory rather than path itself: editors and
+	// config-management tools commonly replace a file by writing a temp
+	// file and renaming it over the original, which moves the watch off
+	// path's old inode and silently stops delivering events.
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	return &ConfigWatcher{
+		path:      filepath.Clean(path),
+		collector: collector,
+		watcher:   watcher,
+		sighup:    sighup,
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start runs the watch loop in a background goroutine until Close is called.
+func (cw *ConfigWatcher) Start() {
+	go func() {
+		for {
+			select {
+			case event, ok := <-cw.watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != cw.path {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					cw.reload()
+				}
+			case err, ok := <-cw.watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config watcher error: %v", err)
+			case <-cw.sighup:
+				log.Printf("Received SIGHUP, reloading %s", cw.path)
+				cw.reload()
+			case <-cw.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the watch loop and releases the underlying fsnotify watcher.
+func (cw *ConfigWatcher) Close() error {
+	signal.Stop(cw.sighup)
+	close(cw.done)
+	return cw.watcher.Close()
+}
+
+// reload re-reads and validates servers.json, swaps it into the collector on
+// success, and cleans up metric series for any servers that were removed.
+func (cw *ConfigWatcher) reload() {
+	servers, err := loadServers(cw.path)
+	if err != nil {
+		log.Printf("Config reload failed: %v", err)
+		configReloadTotal.WithLabelValues("failure").Inc()
+		return
+	}
+
+	if err := validateServers(servers); err != nil {
+		log.Printf("Config reload failed: %v", err)
+		configReloadTotal.WithLabelValues("failure").Inc()
+		return
+	}
+
+	removed := removedServerNames(cw.collector.getServers(), servers)
+	cw.collector.setServers(servers)
+
+	for _, name := range removed {
+		labels := prometheus.Labels{"server_short_name": name}
+		fridaSquadPlayerCount.DeletePartialMatch(labels)
+		fridaSquadPlayTime.DeletePartialMatch(labels)
+		fridaSquadServerInfo.DeletePartialMatch(labels)
+	}
+
+	log.Printf("Reloaded %s: %d servers (%d removed)", cw.path, len(servers), len(removed))
+	configReloadTotal.WithLabelValues("success").Inc()
+}
+
+// validateServers rejects configs with duplicate names, or a missing
+// endpoint for the server's source type: a well-formed URL for
+// battlemetrics, a non-empty Address for a2s and rcon.
+func validateServers(servers []Server) error {
+	seen := make(map[string]struct{}, len(servers))
+	for _, server := range servers {
+		if _, ok := seen[server.Name]; ok {
+			return fmt.Errorf("duplicate server name %q", server.Name)
+		}
+		seen[server.Name] = struct{}{}
+
+		switch server.sourceType() {
+		case sourceTypeBattleMetrics:
+			u, err := url.Parse(server.URL)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid URL %q for server %q", server.URL, server.Name)
+			}
+		case sourceTypeA2S, sourceTypeRCON:
+			if server.Address == "" {
+				return fmt.Errorf("missing Address for %s server %q", server.Type, server.Name)
+			}
+		default:
+			return fmt.Errorf("unknown source type %q for server %q", server.Type, server.Name)
+		}
+	}
+	return nil
+}
+
+// removedServerNames returns the names present in oldServers but absent from newServers.
+func removedServerNames(oldServers, newServers []Server) []string {
+	current := make(map[string]struct{}, len(newServers))
+	for _, server := range newServers {
+		current[server.Name] = struct{}{}
+	}
+
+	var removed []string
+	for _, server := range oldServers {
+		if _, ok := current[server.Name]; !ok {
+			removed = append(removed, server.Name)
+		}
+	}
+	return removed
+}
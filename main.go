@@ -3,41 +3,52 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/singleflight"
 	"golang.org/x/time/rate"
 )
 
-// Server represents a server configuration
+// defaultConcurrency bounds how many servers are scraped in parallel when
+// Concurrency is left unset on a MetricsCollector.
+const defaultConcurrency = 4
+
+// Server represents a server configuration. Type selects which ServerSource
+// fetches it; it defaults to "battlemetrics" for backward compatibility with
+// existing servers.json files that predate the other source types.
 type Server struct {
 	Name string `json:"Name"`
 	URL  string `json:"Url"`
+
+	// Type is one of "battlemetrics", "a2s", or "rcon".
+	Type string `json:"Type,omitempty"`
+
+	// Address is the "host:port" used by the a2s and rcon source types.
+	Address string `json:"Address,omitempty"`
+
+	// RCONPassword authenticates against the rcon source type.
+	RCONPassword string `json:"RconPassword,omitempty"`
 }
 
-// BattleMetricsResponse represents the API response structure (simplified)
-type BattleMetricsResponse struct {
-	Data struct {
-		Attributes struct {
-			Name    string `json:"name"`
-			Players int    `json:"players"`
-			Details struct {
-				Map           string `json:"map"`
-				GameMode      string `json:"gameMode"`
-				SquadPlayTime int    `json:"squad_playTime"`
-				SquadTeamOne  string `json:"squad_teamOne"`
-				SquadTeamTwo  string `json:"squad_teamTwo"`
-			} `json:"details"`
-		} `json:"attributes"`
-	} `json:"data"`
+// sourceType returns the configured source type, defaulting to BattleMetrics.
+func (s Server) sourceType() string {
+	if s.Type == "" {
+		return sourceTypeBattleMetrics
+	}
+	return s.Type
 }
 
 // Prometheus metrics
@@ -82,13 +93,65 @@ var (
 		},
 		[]string{"server_name"},
 	)
+
+	// scrapeDuration tracks per-server fetch latency as a native histogram
+	// (sparse buckets), giving high-resolution percentiles per server without
+	// exploding series cardinality the way fixed classic buckets would.
+	// Despite the metric name, fetches from every source type (BattleMetrics,
+	// A2S and RCON) are observed here.
+	scrapeDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:                            "sqts_battlemetrics_scrape_duration_seconds",
+			Help:                            "Duration of BattleMetrics scrape requests by outcome",
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: time.Hour,
+		},
+		[]string{"server_short_name", "outcome"},
+	)
+
+	// playerCountDistribution samples the player count observed on every
+	// scrape so operators can query quantiles across the whole fleet.
+	playerCountDistribution = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sqts_squad_player_count_distribution",
+			Help:    "Distribution of player counts sampled across scrapes",
+			Buckets: prometheus.LinearBuckets(0, 10, 10),
+		},
+	)
+
+	configReloadTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sqts_config_reload_total",
+			Help: "Total number of servers.json reload attempts",
+		},
+		[]string{"result"},
+	)
 )
 
 // MetricsCollector handles collecting metrics from BattleMetrics API
 type MetricsCollector struct {
-	servers     []Server
+	// servers is swapped atomically so a config reload never races with an
+	// in-progress collection cycle reading the list.
+	servers     atomic.Pointer[[]Server]
 	httpClient  *http.Client
 	rateLimiter *rate.Limiter
+
+	// Concurrency bounds how many servers are scraped in parallel per
+	// collection cycle. The shared rateLimiter still caps the overall
+	// request rate against BattleMetrics regardless of this value.
+	Concurrency int
+
+	// sfGroup deduplicates concurrent fetches for the same server name so a
+	// slow in-flight scrape started by the periodic ticker isn't repeated
+	// by an overlapping tick or an ad-hoc on-demand trigger. Keyed by Name
+	// rather than URL since a2s/rcon servers leave URL empty.
+	sfGroup singleflight.Group
+
+	// remoteWrite, when set, pushes every scraped sample to a remote_write
+	// endpoint in addition to serving them on /metrics; /metrics is always
+	// served and cannot be turned off.
+	remoteWrite *RemoteWriteClient
 }
 
 // NewMetricsCollector creates a new metrics collector with rate limiting
@@ -97,84 +160,138 @@ func NewMetricsCollector(servers []Server) *MetricsCollector {
 	// We'll use 1 request per second average with burst of 10 to be safe
 	rateLimiter := rate.NewLimiter(rate.Every(time.Second), 10)
 
-	return &MetricsCollector{
-		servers:     servers,
+	mc := &MetricsCollector{
 		httpClient:  &http.Client{Timeout: 10 * time.Second},
 		rateLimiter: rateLimiter,
+		Concurrency: defaultConcurrency,
 	}
+	mc.setServers(servers)
+	return mc
 }
 
-// fetchServerData fetches data from BattleMetrics API for a single server
-func (mc *MetricsCollector) fetchServerData(server Server) error {
-	// Wait for rate limiter permission
-	ctx := context.Background()
-	if err := mc.rateLimiter.Wait(ctx); err != nil {
-		return fmt.Errorf("rate limiter error for server %s: %w", server.Name, err)
+// scrapeServer fetches a snapshot for a single server, collapsing concurrent
+// callers for the same server name into a single in-flight request.
+func (mc *MetricsCollector) scrapeServer(server Server) (ServerSnapshot, error) {
+	v, err, _ := mc.sfGroup.Do(server.Name, func() (interface{}, error) {
+		return mc.fetchServerData(server)
+	})
+	if err != nil {
+		return ServerSnapshot{}, err
 	}
+	return v.(ServerSnapshot), nil
+}
+
+// getServers returns the current server list.
+func (mc *MetricsCollector) getServers() []Server {
+	return *mc.servers.Load()
+}
 
-	resp, err := mc.httpClient.Get(server.URL)
+// setServers atomically swaps the server list, e.g. after a config reload.
+func (mc *MetricsCollector) setServers(servers []Server) {
+	mc.servers.Store(&servers)
+}
+
+// fetchServerData fetches a snapshot for a single server from its
+// configured ServerSource (BattleMetrics, A2S, or RCON), updates the global
+// metrics, and returns the snapshot for callers that need the raw data
+// (e.g. the /probe handler).
+func (mc *MetricsCollector) fetchServerData(server Server) (ServerSnapshot, error) {
+	source, err := mc.sourceFor(server)
 	if err != nil {
 		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to fetch data for server %s: %w", server.Name, err)
+		return ServerSnapshot{}, fmt.Errorf("failed to build source for server %s: %w", server.Name, err)
 	}
-	defer func() {
-		if closeErr := resp.Body.Close(); closeErr != nil {
-			log.Printf("Failed to close response body for server %s: %v", server.Name, closeErr)
-		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("unexpected status code %d for server %s", resp.StatusCode, server.Name)
+	ctx := context.Background()
+
+	// Only the BattleMetrics source is subject to the shared quota; A2S and
+	// RCON talk directly to the game server.
+	if server.sourceType() == sourceTypeBattleMetrics {
+		waitStart := time.Now()
+		if err := mc.rateLimiter.Wait(ctx); err != nil {
+			scrapeDuration.WithLabelValues(server.Name, "ratelimit_wait").Observe(time.Since(waitStart).Seconds())
+			return ServerSnapshot{}, fmt.Errorf("rate limiter error for server %s: %w", server.Name, err)
+		}
+		scrapeDuration.WithLabelValues(server.Name, "ratelimit_wait").Observe(time.Since(waitStart).Seconds())
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	requestStart := time.Now()
+
+	snapshot, err := source.Fetch(ctx)
 	if err != nil {
 		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to read response body for server %s: %w", server.Name, err)
+		scrapeDuration.WithLabelValues(server.Name, fetchErrorOutcome(err)).Observe(time.Since(requestStart).Seconds())
+		return ServerSnapshot{}, fmt.Errorf("failed to fetch data for server %s: %w", server.Name, err)
 	}
 
-	var bmResp BattleMetricsResponse
-	if err := json.Unmarshal(body, &bmResp); err != nil {
-		scrapeErrors.WithLabelValues(server.Name).Inc()
-		return fmt.Errorf("failed to unmarshal response for server %s: %w", server.Name, err)
-	}
+	scrapeDuration.WithLabelValues(server.Name, "success").Observe(time.Since(requestStart).Seconds())
 
-	// Update metrics
-	mc.updateMetrics(server.Name, bmResp)
-	return nil
+	mc.updateMetrics(server.Name, snapshot)
+	return snapshot, nil
 }
 
-// updateMetrics updates Prometheus metrics with server data
-func (mc *MetricsCollector) updateMetrics(serverName string, resp BattleMetricsResponse) {
-	attrs := resp.Data.Attributes
-
+// updateMetrics updates Prometheus metrics with a server snapshot
+func (mc *MetricsCollector) updateMetrics(serverName string, snapshot ServerSnapshot) {
 	// Update main metrics with stable labels only
-	fridaSquadPlayerCount.WithLabelValues(serverName).Set(float64(attrs.Players))
-	fridaSquadPlayTime.WithLabelValues(serverName).Set(float64(attrs.Details.SquadPlayTime))
+	fridaSquadPlayerCount.WithLabelValues(serverName).Set(float64(snapshot.Players))
+	fridaSquadPlayTime.WithLabelValues(serverName).Set(float64(snapshot.SquadPlayTime))
+	playerCountDistribution.Observe(float64(snapshot.Players))
 
 	// Update info metric with current metadata (value is always 1)
 	fridaSquadServerInfo.WithLabelValues(
-		serverName,                 // server_short_name
-		attrs.Name,                 // server_full_name
-		attrs.Details.Map,          // map_name
-		attrs.Details.GameMode,     // game_mode
-		attrs.Details.SquadTeamOne, // team_one
-		attrs.Details.SquadTeamTwo, // team_two
+		serverName,            // server_short_name
+		snapshot.Name,         // server_full_name
+		snapshot.Map,          // map_name
+		snapshot.GameMode,     // game_mode
+		snapshot.SquadTeamOne, // team_one
+		snapshot.SquadTeamTwo, // team_two
 	).Set(1)
+
+	if mc.remoteWrite != nil {
+		mc.remoteWrite.EnqueueGauge("sqts_squad_player_count",
+			map[string]string{"server_short_name": serverName}, float64(snapshot.Players))
+		mc.remoteWrite.EnqueueGauge("sqts_squad_play_time_seconds",
+			map[string]string{"server_short_name": serverName}, float64(snapshot.SquadPlayTime))
+		mc.remoteWrite.EnqueueGauge("sqts_squad_server_info", map[string]string{
+			"server_short_name": serverName,
+			"server_full_name":  snapshot.Name,
+			"map_name":          snapshot.Map,
+			"game_mode":         snapshot.GameMode,
+			"team_one":          snapshot.SquadTeamOne,
+			"team_two":          snapshot.SquadTeamTwo,
+		}, 1)
+	}
 }
 
-// collectMetrics fetches data for all servers with rate limiting
+// collectMetrics fetches data for all servers concurrently, bounded by
+// Concurrency workers. The shared rate limiter still caps the rate of the
+// actual BattleMetrics requests to stay within its quota.
 func (mc *MetricsCollector) collectMetrics() {
-	log.Printf("Starting metrics collection for %d servers", len(mc.servers))
+	servers := mc.getServers()
+	log.Printf("Starting metrics collection for %d servers", len(servers))
 
-	// Process servers sequentially to respect rate limits
-	for _, server := range mc.servers {
-		if err := mc.fetchServerData(server); err != nil {
-			log.Printf("Error fetching data for server %s: %v", server.Name, err)
-		}
+	concurrency := mc.Concurrency
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
 	}
 
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for _, server := range servers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(server Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := mc.scrapeServer(server); err != nil {
+				log.Printf("Error fetching data for server %s: %v", server.Name, err)
+			}
+		}(server)
+	}
+
+	wg.Wait()
 	log.Printf("Completed metrics collection")
 }
 
@@ -206,6 +323,11 @@ func loadServers(filename string) ([]Server, error) {
 }
 
 func main() {
+	remoteWriteURL := flag.String("remote-write.url", "", "if set, push scraped samples to this Prometheus remote_write endpoint")
+	remoteWriteBatchSize := flag.Int("remote-write.batch-size", 500, "max samples per remote_write batch")
+	remoteWriteMaxShards := flag.Int("remote-write.max-shards", 4, "max concurrent remote_write shards")
+	flag.Parse()
+
 	// Load server configurations
 	servers, err := loadServers("servers.json")
 	if err != nil {
@@ -216,11 +338,41 @@ func main() {
 	log.Printf("Rate limiting: 1 request/second, collection will take ~%d seconds", len(servers))
 
 	// Register Prometheus metrics
-	prometheus.MustRegister(fridaSquadPlayerCount, fridaSquadPlayTime, fridaSquadServerInfo, scrapeErrors)
+	prometheus.MustRegister(
+		fridaSquadPlayerCount,
+		fridaSquadPlayTime,
+		fridaSquadServerInfo,
+		scrapeErrors,
+		scrapeDuration,
+		playerCountDistribution,
+		configReloadTotal,
+		remoteWriteSamplesTotal,
+	)
 
 	// Create metrics collector
 	collector := NewMetricsCollector(servers)
 
+	var remoteWrite *RemoteWriteClient
+	if *remoteWriteURL != "" {
+		remoteWrite = NewRemoteWriteClient(RemoteWriteConfig{
+			URL:           *remoteWriteURL,
+			BatchSize:     *remoteWriteBatchSize,
+			MaxShards:     *remoteWriteMaxShards,
+			FlushInterval: 5 * time.Second,
+			MaxRetries:    5,
+		})
+		collector.remoteWrite = remoteWrite
+		log.Printf("Remote write enabled: pushing samples to %s", *remoteWriteURL)
+	}
+
+	// Watch servers.json for changes and reload on SIGHUP, so adding or
+	// removing servers doesn't require a restart.
+	configWatcher, err := NewConfigWatcher("servers.json", collector)
+	if err != nil {
+		log.Fatalf("Failed to start config watcher: %v", err)
+	}
+	configWatcher.Start()
+
 	// Start collecting metrics every 60 seconds (gives enough time for all 12 servers)
 	collector.startMetricsCollection(60 * time.Second)
 
@@ -228,12 +380,13 @@ func main() {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger, middleware.Recoverer, middleware.Heartbeat("/health"))
 	r.Handle("/metrics", promhttp.Handler())
+	r.Get("/probe", collector.probeHandler)
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
 			"service":   "Squad Server Metrics",
-			"servers":   len(servers),
-			"endpoints": []string{"/metrics", "/health"},
+			"servers":   len(collector.getServers()),
+			"endpoints": []string{"/metrics", "/probe", "/health"},
 		}); err != nil {
 			log.Printf("Failed to encode JSON response: %v", err)
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -245,6 +398,34 @@ func main() {
 		port = p
 	}
 
-	log.Printf("Starting server on http://localhost:%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	srv := &http.Server{Addr: ":" + port, Handler: r}
+
+	go func() {
+		log.Printf("Starting server on http://localhost:%s", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server error: %v", err)
+		}
+	}()
+
+	// Wait for a shutdown signal so the deferred-equivalent cleanup below
+	// (flushing remote_write, stopping the config watcher) actually runs;
+	// log.Fatal skips deferred functions, so it must not be the exit path.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+
+	log.Printf("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+
+	if err := configWatcher.Close(); err != nil {
+		log.Printf("Config watcher close error: %v", err)
+	}
+	if remoteWrite != nil {
+		remoteWrite.Close()
+	}
 }
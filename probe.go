@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler implements Prometheus's "multi-target exporter" pattern: it
+// synchronously scrapes a single configured server chosen via the `target`
+// query parameter (matched by Name, BattleMetrics server ID, or a2s/rcon
+// Address — see findServerByTarget) and renders the result through a fresh,
+// per-request registry, so Prometheus can schedule probes itself via
+// relabel_configs against a static_configs list of server IDs instead of
+// requiring servers.json to be reloaded for every new server.
+func (mc *MetricsCollector) probeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "missing required query parameter: target", http.StatusBadRequest)
+		return
+	}
+
+	if module := r.URL.Query().Get("module"); module != "" && module != "squad" {
+		http.Error(w, fmt.Sprintf("unsupported module %q", module), http.StatusBadRequest)
+		return
+	}
+
+	server, ok := mc.findServerByTarget(target)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", target), http.StatusNotFound)
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe succeeded (1) or failed (0)",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Duration of the probe in seconds",
+	})
+	registry.MustRegister(probeSuccess, probeDuration)
+
+	start := time.Now()
+	snapshot, err := mc.scrapeServer(server)
+	probeDuration.Set(time.Since(start).Seconds())
+
+	if err != nil {
+		probeSuccess.Set(0)
+	} else {
+		probeSuccess.Set(1)
+		registry.MustRegister(probeSnapshotCollectors(server.Name, snapshot)...)
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeSnapshotCollectors builds gauges scoped to a single server's
+// snapshot, so the response only ever reports the probed target instead of
+// every series the shared, fleet-wide GaugeVecs have accumulated.
+func probeSnapshotCollectors(serverName string, snapshot ServerSnapshot) []prometheus.Collector {
+	playerCount := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqts_squad_player_count",
+			Help: "Number of players on the squad server",
+		},
+		[]string{"server_short_name"},
+	)
+	playTime := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqts_squad_play_time_seconds",
+			Help: "Current round play time in seconds",
+		},
+		[]string{"server_short_name"},
+	)
+	info := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sqts_squad_server_info",
+			Help: "Server information and metadata",
+		},
+		[]string{
+			"server_short_name",
+			"server_full_name",
+			"map_name",
+			"game_mode",
+			"team_one",
+			"team_two",
+		},
+	)
+
+	playerCount.WithLabelValues(serverName).Set(float64(snapshot.Players))
+	playTime.WithLabelValues(serverName).Set(float64(snapshot.SquadPlayTime))
+	info.WithLabelValues(
+		serverName,
+		snapshot.Name,
+		snapshot.Map,
+		snapshot.GameMode,
+		snapshot.SquadTeamOne,
+		snapshot.SquadTeamTwo,
+	).Set(1)
+
+	return []prometheus.Collector{playerCount, playTime, info}
+}
+
+// findServerByTarget looks up a configured server matching target: its
+// configured Name always matches, a BattleMetrics server also matches by
+// server ID (a URL ending in "/servers/<target>"), and an a2s/rcon server
+// also matches by its Address.
+func (mc *MetricsCollector) findServerByTarget(target string) (Server, bool) {
+	for _, server := range mc.getServers() {
+		if server.Name == target {
+			return server, true
+		}
+		switch server.sourceType() {
+		case sourceTypeBattleMetrics:
+			if strings.HasSuffix(strings.TrimRight(server.URL, "/"), "/servers/"+target) {
+				return server, true
+			}
+		case sourceTypeA2S, sourceTypeRCON:
+			if server.Address == target {
+				return server, true
+			}
+		}
+	}
+	return Server{}, false
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Squad implements the Source engine RCON protocol: a length-prefixed
+// little-endian packet framing with SERVERDATA_AUTH / SERVERDATA_EXECCOMMAND
+// request types.
+const (
+	rconPacketTypeAuth         int32 = 3
+	rconPacketTypeAuthResponse int32 = 2
+	rconPacketTypeExecCommand  int32 = 2
+	rconPacketTypeResponseVal  int32 = 0
+
+	rconDialTimeout = 5 * time.Second
+	rconIOTimeout   = 10 * time.Second
+)
+
+// rconConn is an authenticated connection to a Source RCON server.
+type rconConn struct {
+	conn   net.Conn
+	nextID int32
+}
+
+// dialRCON connects to address and authenticates with password.
+func dialRCON(ctx context.Context, address, password string) (*rconConn, error) {
+	dialer := net.Dialer{Timeout: rconDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("dial rcon %s: %w", address, err)
+	}
+
+	rc := &rconConn{conn: conn, nextID: 1}
+	if err := rc.authenticate(password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (rc *rconConn) authenticate(password string) error {
+	id := rc.requestID()
+	if err := rc.send(id, rconPacketTypeAuth, password); err != nil {
+		return fmt.Errorf("rcon auth request: %w", err)
+	}
+
+	// The server always sends an empty SERVERDATA_RESPONSE_VALUE packet
+	// first, then the real SERVERDATA_AUTH_RESPONSE (id == request id on
+	// success, -1 on failure). Always read both packets in that order;
+	// never infer success from the first one.
+	_, firstType, _, err := rc.receive()
+	if err != nil {
+		return fmt.Errorf("rcon auth response: %w", err)
+	}
+	if firstType != rconPacketTypeResponseVal {
+		return fmt.Errorf("unexpected rcon packet type %d before auth response", firstType)
+	}
+
+	respID, respType, _, err := rc.receive()
+	if err != nil {
+		return fmt.Errorf("rcon auth response: %w", err)
+	}
+	if respType != rconPacketTypeAuthResponse || respID != id {
+		return fmt.Errorf("rcon auth rejected")
+	}
+	return nil
+}
+
+// execute runs command and returns the server's response body.
+func (rc *rconConn) execute(command string) (string, error) {
+	id := rc.requestID()
+	if err := rc.send(id, rconPacketTypeExecCommand, command); err != nil {
+		return "", fmt.Errorf("rcon exec %q: %w", command, err)
+	}
+
+	_, _, body, err := rc.receive()
+	if err != nil {
+		return "", fmt.Errorf("rcon response for %q: %w", command, err)
+	}
+	return body, nil
+}
+
+func (rc *rconConn) Close() error {
+	return rc.conn.Close()
+}
+
+func (rc *rconConn) requestID() int32 {
+	id := rc.nextID
+	rc.nextID++
+	return id
+}
+
+// send writes a single RCON packet: int32 size, int32 id, int32 type, body,
+// followed by two null terminators.
+func (rc *rconConn) send(id, packetType int32, body string) error {
+	payload := make([]byte, 0, 14+len(body))
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(id))
+	payload = binary.LittleEndian.AppendUint32(payload, uint32(packetType))
+	payload = append(payload, body...)
+	payload = append(payload, 0, 0)
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(payload)))
+
+	if err := rc.conn.SetWriteDeadline(time.Now().Add(rconIOTimeout)); err != nil {
+		return err
+	}
+	if _, err := rc.conn.Write(append(size, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// receive reads a single RCON packet and returns its id, type, and body. A
+// server that stops responding mid-connection (flaky process, network black
+// hole) would otherwise block this forever and, with it, the worker-pool
+// slot collecting every other server, so every read is bounded by
+// rconIOTimeout.
+func (rc *rconConn) receive() (int32, int32, string, error) {
+	if err := rc.conn.SetReadDeadline(time.Now().Add(rconIOTimeout)); err != nil {
+		return 0, 0, "", err
+	}
+
+	var size int32
+	if err := binary.Read(rc.conn, binary.LittleEndian, &size); err != nil {
+		return 0, 0, "", err
+	}
+
+	packet := make([]byte, size)
+	if _, err := readFull(rc.conn, packet); err != nil {
+		return 0, 0, "", err
+	}
+
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	// Body is everything between the header and the two trailing null bytes.
+	body := string(packet[8 : len(packet)-2])
+	return id, packetType, body, nil
+}
+
+func readFull(conn net.Conn, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := conn.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+var remoteWriteSamplesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sqts_remote_write_samples_total",
+		Help: "Total number of samples pushed via remote_write, by result",
+	},
+	[]string{"result"},
+)
+
+// RemoteWriteConfig configures a RemoteWriteClient.
+type RemoteWriteConfig struct {
+	URL           string
+	BatchSize     int
+	MaxShards     int
+	FlushInterval time.Duration
+	MaxRetries    int
+}
+
+// RemoteWriteClient batches scraped samples into prompb.WriteRequest frames
+// and pushes them to a Prometheus remote_write endpoint, following the
+// queue/shard pattern from Prometheus's own remote write storage: samples
+// are distributed across a fixed number of shards, each batching up to
+// BatchSize samples or flushing every FlushInterval, whichever comes first.
+// This lets sqts run behind NAT or at the edge, pushing to a central
+// Prometheus/Mimir/VictoriaMetrics instead of being scraped directly.
+type RemoteWriteClient struct {
+	cfg        RemoteWriteConfig
+	httpClient *http.Client
+	shards     []chan prompb.TimeSeries
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewRemoteWriteClient creates and starts a RemoteWriteClient's shards.
+func NewRemoteWriteClient(cfg RemoteWriteConfig) *RemoteWriteClient {
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxShards < 1 {
+		cfg.MaxShards = 4
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+
+	rw := &RemoteWriteClient{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		shards:     make([]chan prompb.TimeSeries, cfg.MaxShards),
+		done:       make(chan struct{}),
+	}
+
+	for i := range rw.shards {
+		rw.shards[i] = make(chan prompb.TimeSeries, cfg.BatchSize*2)
+		rw.wg.Add(1)
+		go rw.runShard(rw.shards[i])
+	}
+
+	return rw
+}
+
+// EnqueueGauge builds a single-sample time series for a gauge value and
+// enqueues it for the next batch.
+func (rw *RemoteWriteClient) EnqueueGauge(name string, labels map[string]string, value float64) {
+	pairs := buildLabels(name, labels)
+	series := prompb.TimeSeries{
+		Labels: pairs,
+		Samples: []prompb.Sample{{
+			Value:     value,
+			Timestamp: time.Now().UnixMilli(),
+		}},
+	}
+
+	// Hash on the series identity (name + labels) rather than round-robin,
+	// so every sample for the same series always lands on the same shard
+	// and is flushed in timestamp order.
+	shard := rw.shards[shardFor(pairs)%uint64(len(rw.shards))]
+	select {
+	case shard <- series:
+	default:
+		log.Printf("remote write queue full, dropping sample for %s", name)
+		remoteWriteSamplesTotal.WithLabelValues("dropped").Inc()
+	}
+}
+
+// buildLabels returns name's labels, including __name__, sorted so the
+// resulting prompb.TimeSeries satisfies remote_write's label-ordering
+// requirement.
+func buildLabels(name string, labels map[string]string) []prompb.Label {
+	pairs := make([]prompb.Label, 0, len(labels)+1)
+	pairs = append(pairs, prompb.Label{Name: "__name__", Value: name})
+	for k, v := range labels {
+		pairs = append(pairs, prompb.Label{Name: k, Value: v})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// shardFor hashes a series' sorted labels so every sample belonging to the
+// same series is always routed to the same shard.
+func shardFor(labels []prompb.Label) uint64 {
+	h := fnv.New64a()
+	for _, label := range labels {
+		h.Write([]byte(label.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(label.Value))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func (rw *RemoteWriteClient) runShard(samples chan prompb.TimeSeries) {
+	defer rw.wg.Done()
+
+	ticker := time.NewTicker(rw.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []prompb.TimeSeries
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		rw.send(batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case series := <-samples:
+			batch = append(batch, series)
+			if len(batch) >= rw.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-rw.done:
+			// Drain whatever is still queued on this shard before the final
+			// flush, so Close doesn't drop samples enqueued but not yet read.
+			for {
+				select {
+				case series := <-samples:
+					batch = append(batch, series)
+					if len(batch) >= rw.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send marshals and snappy-compresses batch into a WriteRequest and POSTs
+// it, retrying with exponential backoff on 5xx and honoring a 429's
+// Retry-After header.
+func (rw *RemoteWriteClient) send(batch []prompb.TimeSeries) {
+	data, err := (&prompb.WriteRequest{Timeseries: batch}).Marshal()
+	if err != nil {
+		log.Printf("remote write marshal error: %v", err)
+		remoteWriteSamplesTotal.WithLabelValues("error").Add(float64(len(batch)))
+		return
+	}
+	compressed := snappy.Encode(nil, data)
+
+	backoff := 500 * time.Millisecond
+	maxRetries := rw.cfg.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		wait, done := rw.attemptSend(compressed, len(batch), backoff)
+		if done {
+			return
+		}
+		if attempt == maxRetries {
+			break
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+
+	remoteWriteSamplesTotal.WithLabelValues("error").Add(float64(len(batch)))
+}
+
+// attemptSend issues one POST attempt. It returns the wait duration before
+// the next retry and whether the batch is fully handled (success or a
+// non-retryable error) and no further attempts should be made.
+func (rw *RemoteWriteClient) attemptSend(compressed []byte, sampleCount int, backoff time.Duration) (time.Duration, bool) {
+	req, err := http.NewRequest(http.MethodPost, rw.cfg.URL, bytes.NewReader(compressed))
+	if err != nil {
+		log.Printf("remote write request error: %v", err)
+		return backoff, false
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := rw.httpClient.Do(req)
+	if err != nil {
+		log.Printf("remote write POST error: %v", err)
+		return backoff, false
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		remoteWriteSamplesTotal.WithLabelValues("success").Add(float64(sampleCount))
+		return 0, true
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if secs, err := strconv.Atoi(retryAfter); err == nil {
+				return time.Duration(secs) * time.Second, false
+			}
+		}
+		return backoff, false
+	case resp.StatusCode/100 == 5:
+		return backoff, false
+	default:
+		log.Printf("remote write rejected with status %d", resp.StatusCode)
+		remoteWriteSamplesTotal.WithLabelValues("error").Add(float64(sampleCount))
+		return 0, true
+	}
+}
+
+// Close flushes any pending samples and stops all shards.
+func (rw *RemoteWriteClient) Close() {
+	close(rw.done)
+	rw.wg.Wait()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rumblefrog/go-a2s"
+)
+
+const (
+	sourceTypeBattleMetrics = "battlemetrics"
+	sourceTypeA2S           = "a2s"
+	sourceTypeRCON          = "rcon"
+)
+
+// ServerSnapshot is the source-agnostic point-in-time view of a game server
+// that every ServerSource implementation produces.
+type ServerSnapshot struct {
+	Name          string
+	Players       int
+	Map           string
+	GameMode      string
+	SquadPlayTime int
+	SquadTeamOne  string
+	SquadTeamTwo  string
+}
+
+// ServerSource abstracts fetching a ServerSnapshot, letting MetricsCollector
+// dispatch to BattleMetrics, A2S, or RCON without knowing the transport.
+type ServerSource interface {
+	Fetch(ctx context.Context) (ServerSnapshot, error)
+}
+
+// sourceFor builds the ServerSource for server's configured Type.
+func (mc *MetricsCollector) sourceFor(server Server) (ServerSource, error) {
+	switch server.sourceType() {
+	case sourceTypeBattleMetrics:
+		return &battleMetricsSource{url: server.URL, httpClient: mc.httpClient}, nil
+	case sourceTypeA2S:
+		return &a2sSource{address: server.Address}, nil
+	case sourceTypeRCON:
+		return &rconSource{address: server.Address, password: server.RCONPassword}, nil
+	default:
+		return nil, fmt.Errorf("unknown source type %q", server.Type)
+	}
+}
+
+// fetchStageError annotates a ServerSource error with which stage of the
+// fetch it occurred in, so callers can label the scrapeDuration histogram
+// without each source reimplementing that bookkeeping.
+type fetchStageError struct {
+	stage string
+	err   error
+}
+
+func (e *fetchStageError) Error() string { return e.err.Error() }
+func (e *fetchStageError) Unwrap() error { return e.err }
+
+// fetchErrorOutcome maps a ServerSource error to a scrapeDuration "outcome"
+// label, defaulting to "http_error" for errors sources don't annotate.
+func fetchErrorOutcome(err error) string {
+	var stageErr *fetchStageError
+	if errors.As(err, &stageErr) {
+		return stageErr.stage
+	}
+	return "http_error"
+}
+
+// battleMetricsSource fetches a snapshot from the BattleMetrics public API.
+type battleMetricsSource struct {
+	url        string
+	httpClient *http.Client
+}
+
+// battleMetricsResponse represents the API response structure (simplified)
+type battleMetricsResponse struct {
+	Data struct {
+		Attributes struct {
+			Name    string `json:"name"`
+			Players int    `json:"players"`
+			Details struct {
+				Map           string `json:"map"`
+				GameMode      string `json:"gameMode"`
+				SquadPlayTime int    `json:"squad_playTime"`
+				SquadTeamOne  string `json:"squad_teamOne"`
+				SquadTeamTwo  string `json:"squad_teamTwo"`
+			} `json:"details"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+func (s *battleMetricsSource) Fetch(ctx context.Context) (ServerSnapshot, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", err}
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ServerSnapshot{}, &fetchStageError{"http_error", fmt.Errorf("unexpected status code %d", resp.StatusCode)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"decode_error", err}
+	}
+
+	var bmResp battleMetricsResponse
+	if err := json.Unmarshal(body, &bmResp); err != nil {
+		return ServerSnapshot{}, &fetchStageError{"decode_error", err}
+	}
+
+	attrs := bmResp.Data.Attributes
+	return ServerSnapshot{
+		Name:          attrs.Name,
+		Players:       attrs.Players,
+		Map:           attrs.Details.Map,
+		GameMode:      attrs.Details.GameMode,
+		SquadPlayTime: attrs.Details.SquadPlayTime,
+		SquadTeamOne:  attrs.Details.SquadTeamOne,
+		SquadTeamTwo:  attrs.Details.SquadTeamTwo,
+	}, nil
+}
+
+// a2sSource queries a server directly over the Steam A2S UDP protocol.
+// A2S exposes player count and the current map, but none of Squad's
+// gameplay-specific details (play time, faction names), so those fields are
+// left zero-valued.
+type a2sSource struct {
+	address string
+}
+
+func (s *a2sSource) Fetch(ctx context.Context) (ServerSnapshot, error) {
+	client, err := a2s.NewClient(s.address)
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", fmt.Errorf("dial %s: %w", s.address, err)}
+	}
+	defer client.Close()
+
+	info, err := client.QueryInfo()
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", fmt.Errorf("query info for %s: %w", s.address, err)}
+	}
+
+	return ServerSnapshot{
+		Name:    info.Name,
+		Players: int(info.Players),
+		Map:     info.Map,
+	}, nil
+}
+
+// rconSource queries a server over the Source RCON protocol, which Squad
+// implements. Only the player count is filled in, from "ListPlayers";
+// Name, Map, GameMode, play time and faction names are left zero-valued.
+type rconSource struct {
+	address  string
+	password string
+}
+
+func (s *rconSource) Fetch(ctx context.Context) (ServerSnapshot, error) {
+	conn, err := dialRCON(ctx, s.address, s.password)
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", err}
+	}
+	defer conn.Close()
+
+	players, err := conn.execute("ListPlayers")
+	if err != nil {
+		return ServerSnapshot{}, &fetchStageError{"http_error", err}
+	}
+
+	return ServerSnapshot{
+		Players: countRCONPlayerLines(players),
+	}, nil
+}
+
+// countRCONPlayerLines counts connected players in a Squad "ListPlayers"
+// response. The response lists one player per "ID: " row under an
+// "Active Players" header, followed by a separate "Recently Disconnected
+// Players" section in the same format; only rows in the former count.
+func countRCONPlayerLines(output string) int {
+	count := 0
+	inActiveSection := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.Contains(line, "Active Players"):
+			inActiveSection = true
+		case strings.Contains(line, "Recently Disconnected"):
+			inActiveSection = false
+		case inActiveSection && strings.HasPrefix(line, "ID: "):
+			count++
+		}
+	}
+	return count
+}